@@ -0,0 +1,150 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/odpf/optimus/core/logger"
+	"github.com/odpf/optimus/models"
+)
+
+// ReplayInterruptedByRestart is stored as the failure message for a replay
+// that was found stuck in-progress after a restart and could not be safely
+// resumed.
+const ReplayInterruptedByRestart = "replay interrupted by restart"
+
+// ReplayManager coordinates replay lifecycle concerns that sit above a
+// single worker run: submitting newly created replay requests to the
+// bounded worker pool, and recovering work left behind by a crashed or
+// restarted Optimus process.
+type ReplayManager interface {
+	Submit(projectID, requestID uuid.UUID) error
+	Retry(requestID uuid.UUID) error
+	RecoverInProgress(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+type replayManager struct {
+	replaySpecRepoFac ReplaySpecRepoFactory
+	worker            ReplayWorker
+	pool              *ReplayWorkerPool
+	workerID          string
+
+	// staleAfter is how long a replay can go without a heartbeat before it
+	// is considered abandoned by its previous worker.
+	staleAfter time.Duration
+	// leaseFor is how long this worker holds the lease once it reclaims a
+	// stale replay, giving Process enough time to pick up its own heartbeat.
+	leaseFor time.Duration
+}
+
+// NewReplayManager wires up a ReplayWorkerPool around worker so that both
+// freshly submitted requests (via Submit) and replays reclaimed by
+// RecoverInProgress go through the same bounded, per-project-fair
+// concurrency limits rather than running unbounded.
+func NewReplayManager(ctx context.Context, replaySpecRepoFac ReplaySpecRepoFactory, worker ReplayWorker, workerID string,
+	staleAfter, leaseFor time.Duration, poolSize, perProjectCap, queueSize int) *replayManager {
+	return &replayManager{
+		replaySpecRepoFac: replaySpecRepoFac,
+		worker:            worker,
+		pool:              NewReplayWorkerPool(ctx, worker, poolSize, perProjectCap, queueSize),
+		workerID:          workerID,
+		staleAfter:        staleAfter,
+		leaseFor:          leaseFor,
+	}
+}
+
+// Submit enqueues a newly created replay request onto the bounded worker
+// pool, so a single noisy project cannot starve every other project's
+// replays of Airflow's attention.
+func (m *replayManager) Submit(projectID, requestID uuid.UUID) error {
+	return m.pool.Enqueue(projectID, requestID)
+}
+
+// Close stops the underlying worker pool, waiting for in-flight replays to
+// finish. If ctx is cancelled first, the running replays are handed a
+// cancelled context and Close still waits for them to return.
+func (m *replayManager) Close(ctx context.Context) error {
+	return m.pool.Close(ctx)
+}
+
+// Retry resets a failed or partially-replayed request's non-Success nodes
+// to Pending and resubmits it through the same bounded worker pool as
+// Submit, so a burst of retries is subject to the same per-project
+// fairness as any other replay instead of flooding Airflow directly.
+func (m *replayManager) Retry(requestID uuid.UUID) error {
+	replaySpecRepo := m.replaySpecRepoFac.New()
+	replaySpec, err := replaySpecRepo.GetByID(requestID)
+	if err != nil {
+		return err
+	}
+	if err := replaySpecRepo.Retry(requestID); err != nil {
+		return err
+	}
+	return m.pool.Enqueue(replaySpec.Job.Project.ID, requestID)
+}
+
+// RecoverInProgress scans for replays stuck in ReplayStatusInProgress whose
+// heartbeat has gone stale, meaning the worker handling them likely crashed
+// or was restarted before it could finish. Ownership of each one is
+// reclaimed via a conditional lease update so only one Optimus replica ends
+// up reprocessing it; a replay whose lease cannot be acquired is left for
+// whichever replica currently owns it. Replays that Process leaves
+// in-progress are marked Failed with ReplayInterruptedByRestart rather than
+// left stuck forever. Recovery calls worker.Process directly rather than going through
+// the pool: it is a rare, low-volume path and needs the synchronous result
+// to decide whether to override the final status below.
+func (m *replayManager) RecoverInProgress(ctx context.Context) error {
+	replaySpecRepo := m.replaySpecRepoFac.New()
+	staleReplays, err := replaySpecRepo.GetStaleInProgress(m.staleAfter)
+	if err != nil {
+		return err
+	}
+
+	for _, replaySpec := range staleReplays {
+		leaseUntil := time.Now().UTC().Add(m.leaseFor)
+		acquired, err := replaySpecRepo.AcquireLease(replaySpec.ID, m.workerID, leaseUntil)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			logger.I(fmt.Sprintf("skipping recovery of replay %s, lease held by another worker", replaySpec.ID.String()))
+			continue
+		}
+
+		logger.W(fmt.Sprintf("recovering replay %s left in-progress by a restart", replaySpec.ID.String()))
+		processErr := m.worker.Process(ctx, replaySpec.ID)
+		if processErr != nil {
+			logger.W(fmt.Sprintf("replay %s reported errors during recovery: %s", replaySpec.ID.String(), processErr.Error()))
+		}
+
+		// Process writes its own accurate final status (Replayed, Failed, or
+		// PartiallyReplayed) before returning, even when it returns an error
+		// for some nodes having failed. Only stomp the status with
+		// ReplayInterruptedByRestart when Process could not reach that point
+		// at all and left the replay stuck InProgress, e.g. it failed before
+		// iterating the execution tree.
+		recovered, err := replaySpecRepo.GetByID(replaySpec.ID)
+		if err != nil {
+			return err
+		}
+		if recovered.Status != models.ReplayStatusInProgress {
+			continue
+		}
+
+		logger.W(fmt.Sprintf("failed to recover replay %s: could not reach a final status", replaySpec.ID.String()))
+		if updateErr := replaySpecRepo.UpdateStatus(replaySpec.ID, models.ReplayStatusFailed, models.ReplayMessage{
+			Type:    AirflowClearDagRunFailed,
+			Message: ReplayInterruptedByRestart,
+		}); updateErr != nil {
+			return updateErr
+		}
+		if finishedErr := replaySpecRepo.MarkFinished(replaySpec.ID); finishedErr != nil {
+			return finishedErr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/odpf/optimus/models"
+)
+
+// fakeReplaySpecRepo is an in-memory stand-in for job.ReplaySpecRepository,
+// just enough of one to exercise ReplayManager's Retry and
+// RecoverInProgress without a real database.
+type fakeReplaySpecRepo struct {
+	mu       sync.Mutex
+	specs    map[uuid.UUID]models.ReplaySpec
+	leasedBy map[uuid.UUID]string
+
+	retryErr        error
+	acquireLeaseErr error
+	acquireLease    bool
+	updateStatusLog []string
+}
+
+func (r *fakeReplaySpecRepo) Insert(*models.ReplaySpec) error { return nil }
+
+func (r *fakeReplaySpecRepo) GetByID(id uuid.UUID) (models.ReplaySpec, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spec, ok := r.specs[id]
+	if !ok {
+		return models.ReplaySpec{}, assert.AnError
+	}
+	return spec, nil
+}
+
+func (r *fakeReplaySpecRepo) GetByStatus([]string) ([]models.ReplaySpec, error) { return nil, nil }
+
+func (r *fakeReplaySpecRepo) GetByJobIDAndStatus(uuid.UUID, []string) ([]models.ReplaySpec, error) {
+	return nil, nil
+}
+
+func (r *fakeReplaySpecRepo) UpdateStatus(replayID uuid.UUID, status string, _ models.ReplayMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updateStatusLog = append(r.updateStatusLog, status)
+	spec := r.specs[replayID]
+	spec.Status = status
+	r.specs[replayID] = spec
+	return nil
+}
+
+func (r *fakeReplaySpecRepo) List(models.ReplayListParams) ([]models.ReplaySpec, int64, error) {
+	return nil, 0, nil
+}
+
+func (r *fakeReplaySpecRepo) GetNodesByReplayID(uuid.UUID) ([]models.ReplayNodeSpec, error) {
+	return nil, nil
+}
+
+func (r *fakeReplaySpecRepo) GetFailedNodes(uuid.UUID) ([]models.ReplayNodeSpec, error) {
+	return nil, nil
+}
+
+func (r *fakeReplaySpecRepo) UpdateNodeStatus(uuid.UUID, string, string) error { return nil }
+
+func (r *fakeReplaySpecRepo) MarkStarted(uuid.UUID) error { return nil }
+
+func (r *fakeReplaySpecRepo) MarkFinished(uuid.UUID) error { return nil }
+
+func (r *fakeReplaySpecRepo) Retry(uuid.UUID) error { return r.retryErr }
+
+func (r *fakeReplaySpecRepo) AcquireLease(replayID uuid.UUID, workerID string, _ time.Time) (bool, error) {
+	if r.acquireLeaseErr != nil {
+		return false, r.acquireLeaseErr
+	}
+	if r.acquireLease {
+		r.mu.Lock()
+		r.leasedBy[replayID] = workerID
+		r.mu.Unlock()
+	}
+	return r.acquireLease, nil
+}
+
+func (r *fakeReplaySpecRepo) UpdateHeartbeat(uuid.UUID, string, time.Time) (bool, error) {
+	return true, nil
+}
+
+func (r *fakeReplaySpecRepo) GetStaleInProgress(time.Duration) ([]models.ReplaySpec, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var stale []models.ReplaySpec
+	for _, spec := range r.specs {
+		if spec.Status == models.ReplayStatusInProgress {
+			stale = append(stale, spec)
+		}
+	}
+	return stale, nil
+}
+
+type fakeReplaySpecRepoFactory struct {
+	repo *fakeReplaySpecRepo
+}
+
+func (f *fakeReplaySpecRepoFactory) New() ReplaySpecRepository { return f.repo }
+
+func TestReplayManager_Retry(t *testing.T) {
+	projectID, requestID := uuid.New(), uuid.New()
+
+	t.Run("enqueues onto the pool once the repository resets the replay", func(t *testing.T) {
+		repo := &fakeReplaySpecRepo{specs: map[uuid.UUID]models.ReplaySpec{
+			requestID: {ID: requestID, Status: models.ReplayStatusFailed, Job: models.JobSpec{Project: models.ProjectSpec{ID: projectID}}},
+		}}
+		worker := &fakeReplayWorker{}
+		m := NewReplayManager(context.Background(), &fakeReplaySpecRepoFactory{repo: repo}, worker, "worker-1", time.Minute, time.Minute, 2, 2, 4)
+
+		assert.NoError(t, m.Retry(requestID))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, m.Close(ctx))
+
+		worker.mu.Lock()
+		defer worker.mu.Unlock()
+		assert.Contains(t, worker.processed, requestID)
+	})
+
+	t.Run("propagates a not-retryable replay without touching the pool", func(t *testing.T) {
+		repo := &fakeReplaySpecRepo{
+			specs:    map[uuid.UUID]models.ReplaySpec{requestID: {ID: requestID, Status: models.ReplayStatusInProgress}},
+			retryErr: ErrReplayNotRetryableForTest,
+		}
+		worker := &fakeReplayWorker{}
+		m := NewReplayManager(context.Background(), &fakeReplaySpecRepoFactory{repo: repo}, worker, "worker-1", time.Minute, time.Minute, 2, 2, 4)
+
+		assert.ErrorIs(t, m.Retry(requestID), ErrReplayNotRetryableForTest)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, m.Close(ctx))
+
+		worker.mu.Lock()
+		defer worker.mu.Unlock()
+		assert.Empty(t, worker.processed)
+	})
+}
+
+func TestReplayManager_RecoverInProgress(t *testing.T) {
+	t.Run("skips a replay whose lease could not be acquired", func(t *testing.T) {
+		requestID := uuid.New()
+		repo := &fakeReplaySpecRepo{
+			specs:        map[uuid.UUID]models.ReplaySpec{requestID: {ID: requestID, Status: models.ReplayStatusInProgress}},
+			leasedBy:     map[uuid.UUID]string{},
+			acquireLease: false,
+		}
+		worker := &fakeReplayWorker{}
+		m := NewReplayManager(context.Background(), &fakeReplaySpecRepoFactory{repo: repo}, worker, "worker-1", time.Minute, time.Minute, 2, 2, 4)
+
+		assert.NoError(t, m.RecoverInProgress(context.Background()))
+
+		worker.mu.Lock()
+		defer worker.mu.Unlock()
+		assert.Empty(t, worker.processed)
+	})
+
+	t.Run("does not stomp a legitimate final status reached during recovery", func(t *testing.T) {
+		requestID := uuid.New()
+		repo := &fakeReplaySpecRepo{
+			specs:        map[uuid.UUID]models.ReplaySpec{requestID: {ID: requestID, Status: models.ReplayStatusInProgress}},
+			leasedBy:     map[uuid.UUID]string{},
+			acquireLease: true,
+		}
+		// worker that, as a side effect of Process, moves the replay to a
+		// real terminal status (mirrors replayWorker.Process reaching
+		// PartiallyReplayed and returning a non-nil error for failed nodes).
+		worker := &statusSettingReplayWorker{repo: repo, status: models.ReplayStatusPartiallyReplayed}
+		m := NewReplayManager(context.Background(), &fakeReplaySpecRepoFactory{repo: repo}, worker, "worker-1", time.Minute, time.Minute, 2, 2, 4)
+
+		assert.NoError(t, m.RecoverInProgress(context.Background()))
+
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		assert.Equal(t, models.ReplayStatusPartiallyReplayed, repo.specs[requestID].Status)
+		assert.NotContains(t, repo.updateStatusLog, models.ReplayStatusFailed)
+	})
+}
+
+// ErrReplayNotRetryableForTest stands in for store/postgres's
+// ErrReplayNotRetryable, which this package cannot import without creating
+// an import cycle.
+var ErrReplayNotRetryableForTest = assert.AnError
+
+// statusSettingReplayWorker simulates a worker whose Process call reaches a
+// real terminal status and returns an error anyway (e.g. some nodes
+// failed), so RecoverInProgress must tell that apart from a worker that
+// never got the chance to set a final status at all.
+type statusSettingReplayWorker struct {
+	repo   *fakeReplaySpecRepo
+	status string
+}
+
+func (w *statusSettingReplayWorker) Process(_ context.Context, reqUUID uuid.UUID) error {
+	if err := w.repo.UpdateStatus(reqUUID, w.status, models.ReplayMessage{}); err != nil {
+		return err
+	}
+	return assert.AnError
+}
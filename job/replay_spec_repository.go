@@ -0,0 +1,39 @@
+package job
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/odpf/optimus/models"
+)
+
+// ReplaySpecRepository persists replay requests and the per-node status of
+// their execution tree. Implementations live in store/postgres.
+type ReplaySpecRepository interface {
+	Insert(*models.ReplaySpec) error
+	GetByID(uuid.UUID) (models.ReplaySpec, error)
+	GetByStatus([]string) ([]models.ReplaySpec, error)
+	GetByJobIDAndStatus(jobID uuid.UUID, status []string) ([]models.ReplaySpec, error)
+	UpdateStatus(replayID uuid.UUID, status string, message models.ReplayMessage) error
+	List(models.ReplayListParams) ([]models.ReplaySpec, int64, error)
+
+	GetNodesByReplayID(replayID uuid.UUID) ([]models.ReplayNodeSpec, error)
+	GetFailedNodes(replayID uuid.UUID) ([]models.ReplayNodeSpec, error)
+	UpdateNodeStatus(nodeID uuid.UUID, status string, lastError string) error
+
+	MarkStarted(replayID uuid.UUID) error
+	MarkFinished(replayID uuid.UUID) error
+	Retry(replayID uuid.UUID) error
+
+	AcquireLease(replayID uuid.UUID, workerID string, leaseUntil time.Time) (bool, error)
+	UpdateHeartbeat(replayID uuid.UUID, workerID string, leaseUntil time.Time) (bool, error)
+	GetStaleInProgress(staleAfter time.Duration) ([]models.ReplaySpec, error)
+}
+
+// ReplaySpecRepoFactory constructs a ReplaySpecRepository scoped to a single
+// unit of work, so a long-lived worker or manager never holds on to a
+// single DB transaction/connection for its entire lifetime.
+type ReplaySpecRepoFactory interface {
+	New() ReplaySpecRepository
+}
@@ -15,6 +15,13 @@ import (
 
 const (
 	AirflowClearDagRunFailed = "failed to clear airflow dag run"
+
+	// replayHeartbeatInterval controls how often an in-progress replay's
+	// lease is renewed while this worker is iterating its execution tree.
+	replayHeartbeatInterval = 30 * time.Second
+	// replayLeaseDuration is how long a heartbeat keeps a replay leased to
+	// this worker before it is considered stale and eligible for recovery.
+	replayLeaseDuration = 2 * time.Minute
 )
 
 type ReplayWorker interface {
@@ -24,6 +31,41 @@ type ReplayWorker interface {
 type replayWorker struct {
 	replaySpecRepoFac ReplaySpecRepoFactory
 	scheduler         models.SchedulerUnit
+	workerID          string
+}
+
+// startHeartbeat periodically renews the replay's lease until stopped, so
+// ReplayManager.RecoverInProgress can distinguish a worker that is still
+// alive from one that crashed mid-replay. If a heartbeat finds the lease no
+// longer belongs to this worker (RecoverInProgress reclaimed it from under
+// us), it calls cancel to stop Process from clearing any further DAG runs
+// that the new owner may already be processing.
+func (w *replayWorker) startHeartbeat(ctx context.Context, cancel context.CancelFunc, replaySpecRepo ReplaySpecRepository, reqUUID uuid.UUID) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(replayHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				owned, err := replaySpecRepo.UpdateHeartbeat(reqUUID, w.workerID, time.Now().UTC().Add(replayLeaseDuration))
+				if err != nil {
+					logger.W(fmt.Sprintf("failed to send heartbeat for replay %s: %s", reqUUID.String(), err.Error()))
+					continue
+				}
+				if !owned {
+					logger.W(fmt.Sprintf("lost lease for replay %s to another worker, aborting", reqUUID.String()))
+					cancel()
+					return
+				}
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
 }
 
 func (w *replayWorker) Process(ctx context.Context, reqUUID uuid.UUID) (err error) {
@@ -32,37 +74,118 @@ func (w *replayWorker) Process(ctx context.Context, reqUUID uuid.UUID) (err erro
 	if inProgressErr := replaySpecRepo.UpdateStatus(reqUUID, models.ReplayStatusInProgress, models.ReplayMessage{}); inProgressErr != nil {
 		return inProgressErr
 	}
+	if startedErr := replaySpecRepo.MarkStarted(reqUUID); startedErr != nil {
+		return startedErr
+	}
+	leaseUntil := time.Now().UTC().Add(replayLeaseDuration)
+	owned, err := replaySpecRepo.UpdateHeartbeat(reqUUID, w.workerID, leaseUntil)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		// not leased to this worker yet (e.g. a freshly submitted replay):
+		// take the lease if no other worker holds an unexpired one
+		owned, err = replaySpecRepo.AcquireLease(reqUUID, w.workerID, leaseUntil)
+		if err != nil {
+			return err
+		}
+	}
+	if !owned {
+		return errors.Errorf("could not acquire heartbeat lease for replay %s", reqUUID.String())
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopHeartbeat := w.startHeartbeat(ctx, cancel, replaySpecRepo, reqUUID)
+	defer stopHeartbeat()
 
 	replaySpec, err := replaySpecRepo.GetByID(reqUUID)
 	if err != nil {
 		return err
 	}
 
+	nodeByJobName := map[string]models.ReplayNodeSpec{}
+	for _, node := range replaySpec.Nodes {
+		nodeByJobName[node.JobName] = node
+	}
+
+	var succeeded, failed int
+	var lastErr error
+
 	replayDagsMap := replaySpec.ExecutionTree.GetAllNodes()
 	for _, treeNode := range replayDagsMap {
+		if ctx.Err() != nil {
+			// lost the lease to another worker mid-run; stop clearing nodes
+			// it may already be processing and leave the status alone.
+			break
+		}
+
+		node, ok := nodeByJobName[treeNode.GetName()]
+		if ok && node.Status == models.ReplayStatusSuccess {
+			// already cleared by a previous attempt, nothing to retry here
+			succeeded++
+			continue
+		}
+		if ok {
+			if nodeErr := replaySpecRepo.UpdateNodeStatus(node.ID, models.ReplayStatusInProgress, ""); nodeErr != nil {
+				return nodeErr
+			}
+		}
+
 		runTimes := treeNode.Runs.Values()
 		startTime := runTimes[0].(time.Time)
 		endTime := runTimes[treeNode.Runs.Size()-1].(time.Time)
-		if err = w.scheduler.Clear(ctx, replaySpec.Job.Project, treeNode.GetName(), startTime, endTime); err != nil {
-			err = errors.Wrapf(err, "error while clearing dag runs for job %s", treeNode.GetName())
-			logger.W(fmt.Sprintf("error while running replay %s: %s", reqUUID.String(), err.Error()))
-			if updateStatusErr := replaySpecRepo.UpdateStatus(reqUUID, models.ReplayStatusFailed, models.ReplayMessage{
-				Type:    AirflowClearDagRunFailed,
-				Message: err.Error(),
-			}); updateStatusErr != nil {
-				return updateStatusErr
+		if clearErr := w.scheduler.Clear(ctx, replaySpec.Job.Project, treeNode.GetName(), startTime, endTime); clearErr != nil {
+			clearErr = errors.Wrapf(clearErr, "error while clearing dag runs for job %s", treeNode.GetName())
+			logger.W(fmt.Sprintf("error while running replay %s: %s", reqUUID.String(), clearErr.Error()))
+			failed++
+			lastErr = clearErr
+			if ok {
+				if nodeErr := replaySpecRepo.UpdateNodeStatus(node.ID, models.ReplayStatusFailed, clearErr.Error()); nodeErr != nil {
+					return nodeErr
+				}
+			}
+			continue
+		}
+
+		succeeded++
+		if ok {
+			if nodeErr := replaySpecRepo.UpdateNodeStatus(node.ID, models.ReplayStatusSuccess, ""); nodeErr != nil {
+				return nodeErr
 			}
-			return err
 		}
 	}
 
-	if err = replaySpecRepo.UpdateStatus(reqUUID, models.ReplayStatusReplayed, models.ReplayMessage{}); err != nil {
+	if ctx.Err() != nil {
+		return errors.Wrapf(ctx.Err(), "aborted replay %s after losing its lease", reqUUID.String())
+	}
+
+	finalStatus := models.ReplayStatusReplayed
+	switch {
+	case failed > 0 && succeeded > 0:
+		finalStatus = models.ReplayStatusPartiallyReplayed
+	case failed > 0:
+		finalStatus = models.ReplayStatusFailed
+	}
+
+	message := models.ReplayMessage{}
+	if lastErr != nil {
+		message = models.ReplayMessage{Type: AirflowClearDagRunFailed, Message: lastErr.Error()}
+	}
+	if err = replaySpecRepo.UpdateStatus(reqUUID, finalStatus, message); err != nil {
 		return err
 	}
+	if err = replaySpecRepo.MarkFinished(reqUUID); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d nodes failed while running replay %s", failed, failed+succeeded, reqUUID.String())
+	}
 	logger.I(fmt.Sprintf("successfully cleared instances of replay id: %s", reqUUID.String()))
 	return nil
 }
 
-func NewReplayWorker(replaySpecRepoFac ReplaySpecRepoFactory, scheduler models.SchedulerUnit) *replayWorker {
-	return &replayWorker{replaySpecRepoFac: replaySpecRepoFac, scheduler: scheduler}
+func NewReplayWorker(replaySpecRepoFac ReplaySpecRepoFactory, scheduler models.SchedulerUnit, workerID string) *replayWorker {
+	return &replayWorker{replaySpecRepoFac: replaySpecRepoFac, scheduler: scheduler, workerID: workerID}
 }
@@ -0,0 +1,175 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/odpf/optimus/core/logger"
+)
+
+// ErrReplayWorkerPoolClosed is returned by Enqueue once Close has started,
+// since the request queue is no longer accepting new work.
+var ErrReplayWorkerPoolClosed = errors.New("replay worker pool is closed")
+
+var (
+	replayWorkerPoolProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "replay_worker_pool_processed_total",
+		Help: "Total number of replay requests processed by the replay worker pool",
+	})
+	replayWorkerPoolFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "replay_worker_pool_failed_total",
+		Help: "Total number of replay requests that failed while being processed by the replay worker pool",
+	})
+	replayWorkerPoolQueueTime = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "replay_worker_pool_queue_time_seconds",
+		Help: "Time a replay request spent waiting in the pool queue before a worker picked it up",
+	})
+)
+
+type replayRequest struct {
+	id        uuid.UUID
+	projectID uuid.UUID
+	queuedAt  time.Time
+}
+
+// ReplayWorkerPool runs a bounded number of replayWorker.Process calls
+// concurrently. Requests are fanned out across a fixed number of goroutines,
+// and a per-project semaphore additionally caps how many of a single
+// project's replays can be in flight at once, so one noisy project cannot
+// starve the others of Airflow's attention.
+type ReplayWorkerPool struct {
+	worker ReplayWorker
+
+	requestQueue  chan replayRequest
+	poolSize      int
+	perProjectCap int
+
+	projectSemaphores   map[uuid.UUID]chan struct{}
+	projectSemaphoresMu sync.Mutex
+
+	// closedMu guards closed so Enqueue and Close can never race: Close
+	// takes the write lock before closing requestQueue, and Enqueue holds
+	// the read lock for the duration of its send so it can never land on
+	// an already-closed channel.
+	closedMu sync.RWMutex
+	closed   bool
+
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+// NewReplayWorkerPool starts poolSize goroutines pulling replay requests off
+// a buffered queue of size queueSize, each bounded to perProjectCap
+// concurrent replays per project.
+func NewReplayWorkerPool(ctx context.Context, worker ReplayWorker, poolSize, perProjectCap, queueSize int) *ReplayWorkerPool {
+	poolCtx, cancel := context.WithCancel(ctx)
+	pool := &ReplayWorkerPool{
+		worker:            worker,
+		requestQueue:      make(chan replayRequest, queueSize),
+		poolSize:          poolSize,
+		perProjectCap:     perProjectCap,
+		projectSemaphores: map[uuid.UUID]chan struct{}{},
+		cancel:            cancel,
+	}
+	for i := 0; i < poolSize; i++ {
+		pool.wg.Add(1)
+		go pool.run(poolCtx)
+	}
+	return pool
+}
+
+// Enqueue submits a replay request to be picked up by the next free worker,
+// subject to its project's concurrency cap. It returns ErrReplayWorkerPoolClosed
+// once Close has started instead of sending on a closed channel.
+func (p *ReplayWorkerPool) Enqueue(projectID uuid.UUID, requestID uuid.UUID) error {
+	p.closedMu.RLock()
+	defer p.closedMu.RUnlock()
+	if p.closed {
+		return ErrReplayWorkerPoolClosed
+	}
+	p.requestQueue <- replayRequest{
+		id:        requestID,
+		projectID: projectID,
+		queuedAt:  time.Now().UTC(),
+	}
+	return nil
+}
+
+func (p *ReplayWorkerPool) run(ctx context.Context) {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req, ok := <-p.requestQueue:
+			if !ok {
+				return
+			}
+			replayWorkerPoolQueueTime.Observe(time.Since(req.queuedAt).Seconds())
+			p.process(ctx, req)
+		}
+	}
+}
+
+func (p *ReplayWorkerPool) process(ctx context.Context, req replayRequest) {
+	sem := p.projectSemaphore(req.projectID)
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer func() { <-sem }()
+
+	replayWorkerPoolProcessed.Inc()
+	if err := p.worker.Process(ctx, req.id); err != nil {
+		replayWorkerPoolFailed.Inc()
+		logger.W(fmt.Sprintf("replay worker pool failed to process replay %s: %s", req.id.String(), err.Error()))
+	}
+}
+
+func (p *ReplayWorkerPool) projectSemaphore(projectID uuid.UUID) chan struct{} {
+	p.projectSemaphoresMu.Lock()
+	defer p.projectSemaphoresMu.Unlock()
+	sem, ok := p.projectSemaphores[projectID]
+	if !ok {
+		sem = make(chan struct{}, p.perProjectCap)
+		p.projectSemaphores[projectID] = sem
+	}
+	return sem
+}
+
+// Close stops accepting new requests and waits for the workers to drain the
+// queue and return. If ctx is cancelled first, it cancels the context passed
+// to every Process call, including ones already running, then still waits
+// for all workers to return before reporting ctx.Err().
+func (p *ReplayWorkerPool) Close(ctx context.Context) error {
+	p.closedMu.Lock()
+	alreadyClosed := p.closed
+	p.closed = true
+	if !alreadyClosed {
+		close(p.requestQueue)
+	}
+	p.closedMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
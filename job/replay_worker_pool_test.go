@@ -0,0 +1,76 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeReplayWorker struct {
+	mu        sync.Mutex
+	processed []uuid.UUID
+}
+
+func (w *fakeReplayWorker) Process(_ context.Context, reqUUID uuid.UUID) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.processed = append(w.processed, reqUUID)
+	return nil
+}
+
+func TestReplayWorkerPool_EnqueueAfterClose(t *testing.T) {
+	pool := NewReplayWorkerPool(context.Background(), &fakeReplayWorker{}, 2, 1, 4)
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, pool.Close(closeCtx))
+
+	err := pool.Enqueue(uuid.New(), uuid.New())
+	assert.ErrorIs(t, err, ErrReplayWorkerPoolClosed)
+}
+
+func TestReplayWorkerPool_EnqueueCloseRace(t *testing.T) {
+	worker := &fakeReplayWorker{}
+	pool := NewReplayWorkerPool(context.Background(), worker, 4, 2, 32)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Either outcome is fine, the race detector is what matters here:
+			// Enqueue must never panic with "send on closed channel".
+			_ = pool.Enqueue(uuid.New(), uuid.New())
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = pool.Close(ctx)
+	}()
+
+	wg.Wait()
+}
+
+func TestReplayWorkerPool_ProcessesEnqueuedRequests(t *testing.T) {
+	worker := &fakeReplayWorker{}
+	pool := NewReplayWorkerPool(context.Background(), worker, 2, 2, 4)
+
+	reqID := uuid.New()
+	assert.NoError(t, pool.Enqueue(uuid.New(), reqID))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, pool.Close(ctx))
+
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+	assert.Contains(t, worker.processed, reqID)
+}
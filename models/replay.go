@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/odpf/optimus/core/tree"
+)
+
+// Replay statuses, for the request as a whole. A replay starts Accepted,
+// moves to InProgress once a worker picks it up, and ends at Replayed,
+// Failed, or PartiallyReplayed depending on how many of its nodes
+// succeeded.
+const (
+	ReplayStatusAccepted          = "accepted"
+	ReplayStatusInProgress        = "inprogress"
+	ReplayStatusReplayed          = "replayed"
+	ReplayStatusPartiallyReplayed = "partial replayed"
+	ReplayStatusFailed            = "failed"
+)
+
+// Replay node statuses, tracked independently per job in the execution tree
+// so a partial failure can be inspected and retried without re-running
+// nodes that already succeeded. Pending/Success/Failed mirror the lifecycle
+// a node goes through on a single attempt; Skipped is reserved for a node
+// that is intentionally excluded from a retry (e.g. it sits outside the
+// failed subtree).
+const (
+	ReplayStatusPending = "pending"
+	ReplayStatusSuccess = "success"
+	ReplayStatusSkipped = "skipped"
+)
+
+// ReplayMessage carries the last error, if any, a replay or replay node
+// reported while clearing DAG runs.
+type ReplayMessage struct {
+	Type    string
+	Message string
+}
+
+// ReplaySpec represents a single replay request: clearing and re-running a
+// job's execution tree over a date range.
+type ReplaySpec struct {
+	ID            uuid.UUID
+	Job           JobSpec
+	ExecutionTree *tree.TreeNode
+	Status        string
+	Message       ReplayMessage
+	StartDate     time.Time
+	EndDate       time.Time
+
+	// Nodes tracks the status of each job in ExecutionTree independently, so
+	// the API can surface per-node progress instead of a single replay-wide
+	// status.
+	Nodes []ReplayNodeSpec
+
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ReplayNodeSpec is the status of a single job within a replay's execution
+// tree.
+type ReplayNodeSpec struct {
+	ID            uuid.UUID
+	ReplayID      uuid.UUID
+	JobName       string
+	NamespaceName string
+	ProjectName   string
+	StartDate     time.Time
+	EndDate       time.Time
+	Status        string
+	Attempt       int
+	LastError     string
+	StartedAt     *time.Time
+	FinishedAt    *time.Time
+}
+
+// ReplayListParams filters and paginates the result of
+// ReplaySpecRepository.List. OrderBy is validated against a fixed column
+// allowlist by the repository rather than interpolated as-is, since it is
+// expected to come directly from an API or CLI caller.
+type ReplayListParams struct {
+	JobID         uuid.UUID
+	ProjectID     uuid.UUID
+	Statuses      []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	OrderBy       string
+	Limit         int
+	Offset        int
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -28,6 +29,15 @@ type Replay struct {
 	Message       datatypes.JSON
 	ExecutionTree datatypes.JSON
 
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+
+	// WorkerID and LeaseUntil let multiple Optimus replicas coordinate
+	// ownership of an in-progress replay so only one of them clears DAG
+	// runs for it at a time.
+	WorkerID   *string    `json:"worker_id"`
+	LeaseUntil *time.Time `json:"lease_until"`
+
 	CreatedAt time.Time `gorm:"not null" json:"created_at"`
 	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
 }
@@ -78,6 +88,8 @@ func (p Replay) FromSpec(spec *models.ReplaySpec) (Replay, error) {
 		Status:        spec.Status,
 		Message:       message,
 		ExecutionTree: executionTree,
+		StartedAt:     spec.StartedAt,
+		FinishedAt:    spec.FinishedAt,
 	}, nil
 }
 
@@ -96,6 +108,12 @@ func toTreeNode(executionTree *ExecutionTree) *tree.TreeNode {
 	return treeNode
 }
 
+// ToSpec reconstructs ExecutionTree's shape from the stored JSON blob, since
+// the dependency edges between nodes aren't persisted anywhere else. Per-node
+// progress (status, attempt, last error) is deliberately NOT read from that
+// blob: it is attached separately from the ReplayNode rows by the
+// repository's attachNodes, which is the source of truth for progress and
+// the thing UpdateNodeStatus actually writes to as a replay runs.
 func (p Replay) ToSpec(jobSpec models.JobSpec) (models.ReplaySpec, error) {
 	message := models.ReplayMessage{}
 	if err := json.Unmarshal(p.Message, &message); err != nil {
@@ -116,9 +134,56 @@ func (p Replay) ToSpec(jobSpec models.JobSpec) (models.ReplaySpec, error) {
 		Message:       message,
 		ExecutionTree: toTreeNode(&executionTree),
 		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.UpdatedAt,
+		StartedAt:     p.StartedAt,
+		FinishedAt:    p.FinishedAt,
 	}, nil
 }
 
+// ReplayNode is a single row per DAG node in a replay's execution tree, tracked
+// independently from the parent Replay so that progress and failures can be
+// observed (and eventually retried) at the node level instead of only at the
+// top level.
+type ReplayNode struct {
+	ID       uuid.UUID `gorm:"primary_key;type:uuid"`
+	ReplayID uuid.UUID `gorm:"not null"`
+	Replay   Replay    `gorm:"foreignKey:ReplayID"`
+
+	JobName       string `gorm:"not null"`
+	NamespaceName string
+	ProjectName   string
+
+	StartDate time.Time `gorm:"not null"`
+	EndDate   time.Time `gorm:"not null"`
+
+	Status    string `gorm:"not null"`
+	Attempt   int
+	LastError string
+
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+
+	CreatedAt time.Time `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+func (n ReplayNode) ToSpec() models.ReplayNodeSpec {
+	return models.ReplayNodeSpec{
+		ID:            n.ID,
+		ReplayID:      n.ReplayID,
+		JobName:       n.JobName,
+		NamespaceName: n.NamespaceName,
+		ProjectName:   n.ProjectName,
+		StartDate:     n.StartDate,
+		EndDate:       n.EndDate,
+		Status:        n.Status,
+		Attempt:       n.Attempt,
+		LastError:     n.LastError,
+		StartedAt:     n.StartedAt,
+		FinishedAt:    n.FinishedAt,
+	}
+}
+
 type replayRepository struct {
 	DB      *gorm.DB
 	adapter *JobSpecAdapter
@@ -133,12 +198,56 @@ func NewReplayRepository(db *gorm.DB, jobAdapter *JobSpecAdapter, hash models.Ap
 	}
 }
 
+// Insert creates the parent Replay row together with its per-node
+// ReplayNode rows in a single transaction, so a failure partway through
+// insertNodes can never leave behind a Replay with an incomplete or
+// inconsistent set of nodes.
 func (repo *replayRepository) Insert(replay *models.ReplaySpec) error {
 	r, err := Replay{}.FromSpec(replay)
 	if err != nil {
 		return err
 	}
-	return repo.DB.Create(&r).Error
+
+	tx := repo.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := tx.Create(&r).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if replay.ExecutionTree != nil {
+		if err := insertNodes(tx, r.ID, replay.ExecutionTree); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// insertNodes materializes one ReplayNode row per node in the execution tree
+// so that each DAG node's status can be tracked and queried independently of
+// the parent Replay.
+func insertNodes(db *gorm.DB, replayID uuid.UUID, executionTree *tree.TreeNode) error {
+	for _, treeNode := range executionTree.GetAllNodes() {
+		jobSpec := treeNode.Data.(models.JobSpec)
+		runs := treeNode.Runs.Values()
+
+		node := ReplayNode{
+			ID:            uuid.New(),
+			ReplayID:      replayID,
+			JobName:       jobSpec.Name,
+			NamespaceName: jobSpec.NamespaceSpec.Name,
+			ProjectName:   jobSpec.NamespaceSpec.ProjectSpec.Name,
+			StartDate:     runs[0].(time.Time),
+			EndDate:       runs[len(runs)-1].(time.Time),
+			Status:        models.ReplayStatusPending,
+		}
+		if err := db.Create(&node).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (repo *replayRepository) GetByID(id uuid.UUID) (models.ReplaySpec, error) {
@@ -159,13 +268,139 @@ func (repo *replayRepository) GetByID(id uuid.UUID) (models.ReplaySpec, error) {
 		return models.ReplaySpec{}, err
 	}
 	jobSpec.Project = projectSpec
-	return r.ToSpec(jobSpec)
+	replaySpec, err := r.ToSpec(jobSpec)
+	if err != nil {
+		return models.ReplaySpec{}, err
+	}
+	if err := repo.attachNodes(&replaySpec); err != nil {
+		return models.ReplaySpec{}, err
+	}
+	return replaySpec, nil
+}
+
+// attachNodes populates replaySpec.Nodes from its ReplayNode rows. Every
+// read path returns node-level progress through this same helper rather
+// than only GetByID, since a caller inspecting GetByStatus/List results has
+// the same need to see which nodes succeeded or failed.
+func (repo *replayRepository) attachNodes(replaySpec *models.ReplaySpec) error {
+	nodes, err := repo.GetNodesByReplayID(replaySpec.ID)
+	if err != nil && !errors.Is(err, store.ErrResourceNotFound) {
+		return err
+	}
+	replaySpec.Nodes = nodes
+	return nil
+}
+
+// attachNodesToAll runs attachNodes over a slice of replaySpecs in place.
+func (repo *replayRepository) attachNodesToAll(replaySpecs []models.ReplaySpec) error {
+	for i := range replaySpecs {
+		if err := repo.attachNodes(&replaySpecs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *replayRepository) GetNodesByReplayID(replayID uuid.UUID) ([]models.ReplayNodeSpec, error) {
+	var nodes []ReplayNode
+	if err := repo.DB.Where("replay_id = ?", replayID).Find(&nodes).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []models.ReplayNodeSpec{}, store.ErrResourceNotFound
+		}
+		return []models.ReplayNodeSpec{}, err
+	}
+
+	var nodeSpecs []models.ReplayNodeSpec
+	for _, n := range nodes {
+		nodeSpecs = append(nodeSpecs, n.ToSpec())
+	}
+	return nodeSpecs, nil
+}
+
+func (repo *replayRepository) GetFailedNodes(replayID uuid.UUID) ([]models.ReplayNodeSpec, error) {
+	var nodes []ReplayNode
+	if err := repo.DB.Where("replay_id = ? and status = ?", replayID, models.ReplayStatusFailed).
+		Find(&nodes).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []models.ReplayNodeSpec{}, store.ErrResourceNotFound
+		}
+		return []models.ReplayNodeSpec{}, err
+	}
+
+	var nodeSpecs []models.ReplayNodeSpec
+	for _, n := range nodes {
+		nodeSpecs = append(nodeSpecs, n.ToSpec())
+	}
+	return nodeSpecs, nil
+}
+
+// ErrReplayNotRetryable is returned by Retry when the replay is not in a
+// terminal failed/partially-replayed state. Resetting nodes on a replay
+// that is still accepted/in-progress would start a second concurrent
+// Process over the same ReplayNode rows and DAG clears.
+var ErrReplayNotRetryable = errors.New("replay is not in a retryable state")
+
+// Retry resubmits a replay for processing: nodes already in
+// ReplayStatusSuccess are left untouched, while anything else (Failed or
+// Pending) is reset to Pending so the worker re-runs only what did not
+// previously succeed. Only replays that already reached a terminal Failed
+// or PartiallyReplayed status are eligible.
+func (repo *replayRepository) Retry(replayID uuid.UUID) error {
+	var r Replay
+	if err := repo.DB.Where("id = ?", replayID).First(&r).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return store.ErrResourceNotFound
+		}
+		return err
+	}
+	switch r.Status {
+	case models.ReplayStatusFailed, models.ReplayStatusPartiallyReplayed:
+	default:
+		return ErrReplayNotRetryable
+	}
+
+	r.Status = models.ReplayStatusAccepted
+	r.FinishedAt = nil
+	if err := repo.DB.Save(&r).Error; err != nil {
+		return err
+	}
+
+	return repo.DB.Model(&ReplayNode{}).
+		Where("replay_id = ? and status <> ?", replayID, models.ReplayStatusSuccess).
+		Updates(map[string]interface{}{"status": models.ReplayStatusPending, "last_error": ""}).Error
+}
+
+// UpdateNodeStatus updates the status of a single replay node, tracking
+// attempts and the started/finished timestamps as it transitions.
+func (repo *replayRepository) UpdateNodeStatus(nodeID uuid.UUID, status string, lastError string) error {
+	var n ReplayNode
+	if err := repo.DB.Where("id = ?", nodeID).First(&n).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return store.ErrResourceNotFound
+		}
+		return err
+	}
+
+	now := time.Now().UTC()
+	n.Status = status
+	n.LastError = lastError
+	switch status {
+	case models.ReplayStatusInProgress:
+		n.Attempt++
+		n.StartedAt = &now
+	case models.ReplayStatusSuccess, models.ReplayStatusFailed, models.ReplayStatusSkipped:
+		n.FinishedAt = &now
+	}
+	return repo.DB.Save(&n).Error
 }
 
 func (repo *replayRepository) UpdateStatus(replayID uuid.UUID, status string, message models.ReplayMessage) error {
 	var r Replay
-	if err := repo.DB.Where("id = ?", replayID).Find(&r).Error; err != nil {
-		return errors.New("could not update non-existing replay")
+	if err := repo.DB.Where("id = ?", replayID).First(&r).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return store.ErrResourceNotFound
+		}
+		return err
 	}
 	jsonBytes, err := json.Marshal(message)
 	if err != nil {
@@ -176,6 +411,103 @@ func (repo *replayRepository) UpdateStatus(replayID uuid.UUID, status string, me
 	return repo.DB.Save(&r).Error
 }
 
+// MarkStarted records the time a replay actually started clearing DAG runs.
+func (repo *replayRepository) MarkStarted(replayID uuid.UUID) error {
+	var r Replay
+	if err := repo.DB.Where("id = ?", replayID).First(&r).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return store.ErrResourceNotFound
+		}
+		return err
+	}
+	now := time.Now().UTC()
+	r.StartedAt = &now
+	return repo.DB.Save(&r).Error
+}
+
+// MarkFinished records the time a replay stopped processing, whether it
+// succeeded or failed.
+func (repo *replayRepository) MarkFinished(replayID uuid.UUID) error {
+	var r Replay
+	if err := repo.DB.Where("id = ?", replayID).First(&r).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return store.ErrResourceNotFound
+		}
+		return err
+	}
+	now := time.Now().UTC()
+	r.FinishedAt = &now
+	return repo.DB.Save(&r).Error
+}
+
+// AcquireLease tries to take over ownership of a replay for workerID,
+// succeeding only if no worker, workerID included, currently holds an
+// unexpired lease.
+// The conditional update doubles as the compare-and-swap that lets
+// multiple Optimus replicas coordinate without double-processing a replay.
+func (repo *replayRepository) AcquireLease(replayID uuid.UUID, workerID string, leaseUntil time.Time) (bool, error) {
+	result := repo.DB.Model(&Replay{}).
+		Where("id = ? and (lease_until is null or lease_until < ?)", replayID, time.Now().UTC()).
+		Updates(map[string]interface{}{"worker_id": workerID, "lease_until": leaseUntil})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateHeartbeat bumps a replay's lease while a worker is still actively
+// iterating its execution tree, so RecoverInProgress can tell a live replay
+// apart from one whose worker crashed mid-way. The update is conditional on
+// workerID still owning the lease, so a worker whose lease was reclaimed by
+// RecoverInProgress (e.g. after a slow GC pause rather than an actual crash)
+// notices via the returned bool instead of silently stealing it back.
+func (repo *replayRepository) UpdateHeartbeat(replayID uuid.UUID, workerID string, leaseUntil time.Time) (bool, error) {
+	result := repo.DB.Model(&Replay{}).Where("id = ? and worker_id = ?", replayID, workerID).
+		Updates(map[string]interface{}{"lease_until": leaseUntil, "updated_at": time.Now().UTC()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetStaleInProgress returns replays stuck in InProgress whose heartbeat
+// has not been updated within staleAfter, meaning the worker that owned
+// them has likely crashed or been restarted.
+func (repo *replayRepository) GetStaleInProgress(staleAfter time.Duration) ([]models.ReplaySpec, error) {
+	var replays []Replay
+	threshold := time.Now().UTC().Add(-staleAfter)
+	if err := repo.DB.Where("status = ? and updated_at < ?", models.ReplayStatusInProgress, threshold).
+		Preload("Job").Preload("Job.Project").Preload("Job.Project.Secrets").Find(&replays).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []models.ReplaySpec{}, store.ErrResourceNotFound
+		}
+		return []models.ReplaySpec{}, err
+	}
+
+	var replaySpecs []models.ReplaySpec
+	for _, r := range replays {
+		jobSpec, err := repo.adapter.ToSpec(r.Job)
+		if err != nil {
+			return []models.ReplaySpec{}, err
+		}
+		projectSpec, err := r.Job.Project.ToSpecWithSecrets(repo.hash)
+		if err != nil {
+			return []models.ReplaySpec{}, err
+		}
+		jobSpec.Project = projectSpec
+
+		replaySpec, err := r.ToSpec(jobSpec)
+		if err != nil {
+			return []models.ReplaySpec{}, err
+		}
+		replaySpecs = append(replaySpecs, replaySpec)
+	}
+	if err := repo.attachNodesToAll(replaySpecs); err != nil {
+		return []models.ReplaySpec{}, err
+	}
+	return replaySpecs, nil
+}
+
 func (repo *replayRepository) GetByStatus(status []string) ([]models.ReplaySpec, error) {
 	var replays []Replay
 	if err := repo.DB.Where("status in (?)", status).Preload("Job").
@@ -204,6 +536,9 @@ func (repo *replayRepository) GetByStatus(status []string) ([]models.ReplaySpec,
 		}
 		replaySpecs = append(replaySpecs, replaySpec)
 	}
+	if err := repo.attachNodesToAll(replaySpecs); err != nil {
+		return []models.ReplaySpec{}, err
+	}
 	return replaySpecs, nil
 }
 
@@ -228,5 +563,134 @@ func (repo *replayRepository) GetByJobIDAndStatus(jobID uuid.UUID, status []stri
 		}
 		replaySpecs = append(replaySpecs, replaySpec)
 	}
+	if err := repo.attachNodesToAll(replaySpecs); err != nil {
+		return []models.ReplaySpec{}, err
+	}
 	return replaySpecs, nil
 }
+
+// defaultReplayListLimit and maxReplayListLimit bound the page size List
+// returns: callers that omit a limit get a sane default instead of every
+// matching row, and callers that ask for too much are capped rather than
+// trusted, keeping this a real pagination API rather than a glorified
+// GetByStatus.
+const (
+	defaultReplayListLimit = 100
+	maxReplayListLimit     = 500
+)
+
+// replayListLimit clamps a caller-supplied limit to (0, maxReplayListLimit],
+// falling back to defaultReplayListLimit when the caller didn't ask for one.
+func replayListLimit(requested int) int {
+	switch {
+	case requested <= 0:
+		return defaultReplayListLimit
+	case requested > maxReplayListLimit:
+		return maxReplayListLimit
+	default:
+		return requested
+	}
+}
+
+// defaultReplayOrderBy is used whenever the caller didn't ask for a specific
+// order, or asked for a column that isn't in replayOrderColumns.
+const defaultReplayOrderBy = "created_at desc"
+
+// replayOrderColumns allowlists the columns List can sort by. OrderBy comes
+// straight from an API/CLI caller, so it is validated against this set
+// instead of being interpolated into the query as-is.
+var replayOrderColumns = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"status":     true,
+}
+
+// replayOrderBy validates a caller-supplied "column direction" order clause
+// against replayOrderColumns, falling back to defaultReplayOrderBy for
+// anything not on the allowlist.
+func replayOrderBy(requested string) string {
+	fields := strings.Fields(requested)
+	if len(fields) == 0 || len(fields) > 2 || !replayOrderColumns[fields[0]] {
+		return defaultReplayOrderBy
+	}
+	direction := "asc"
+	if len(fields) == 2 {
+		switch strings.ToLower(fields[1]) {
+		case "asc", "desc":
+			direction = strings.ToLower(fields[1])
+		default:
+			return defaultReplayOrderBy
+		}
+	}
+	return fields[0] + " " + direction
+}
+
+// List returns replays matching params along with the total number of rows
+// that matched before pagination, pushing every filter into the SQL query
+// rather than filtering in Go. The add_replay_list_indexes migration indexes
+// job_id, status, updated_at, and created_at to keep this performant as the
+// replay table grows.
+func (repo *replayRepository) List(params models.ReplayListParams) ([]models.ReplaySpec, int64, error) {
+	query := repo.DB.Model(&Replay{})
+	if params.JobID != uuid.Nil {
+		query = query.Where("job_id = ?", params.JobID)
+	}
+	if params.ProjectID != uuid.Nil {
+		projectJobIDs := repo.DB.Model(&Job{}).Where("project_id = ?", params.ProjectID).Select("id").SubQuery()
+		query = query.Where("job_id in (?)", projectJobIDs)
+	}
+	if len(params.Statuses) > 0 {
+		query = query.Where("status in (?)", params.Statuses)
+	}
+	if params.CreatedAfter != nil {
+		query = query.Where("created_at > ?", *params.CreatedAfter)
+	}
+	if params.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *params.CreatedBefore)
+	}
+	if params.UpdatedAfter != nil {
+		query = query.Where("updated_at > ?", *params.UpdatedAfter)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return []models.ReplaySpec{}, 0, err
+	}
+
+	listQuery := query.Order(replayOrderBy(params.OrderBy)).Preload("Job").Preload("Job.Project").Preload("Job.Project.Secrets").
+		Limit(replayListLimit(params.Limit))
+	if params.Offset > 0 {
+		listQuery = listQuery.Offset(params.Offset)
+	}
+
+	var replays []Replay
+	if err := listQuery.Find(&replays).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []models.ReplaySpec{}, total, nil
+		}
+		return []models.ReplaySpec{}, 0, err
+	}
+
+	replaySpecs := make([]models.ReplaySpec, 0, len(replays))
+	for _, r := range replays {
+		jobSpec, err := repo.adapter.ToSpec(r.Job)
+		if err != nil {
+			return []models.ReplaySpec{}, 0, err
+		}
+		projectSpec, err := r.Job.Project.ToSpecWithSecrets(repo.hash)
+		if err != nil {
+			return []models.ReplaySpec{}, 0, err
+		}
+		jobSpec.Project = projectSpec
+
+		replaySpec, err := r.ToSpec(jobSpec)
+		if err != nil {
+			return []models.ReplaySpec{}, 0, err
+		}
+		replaySpecs = append(replaySpecs, replaySpec)
+	}
+	if err := repo.attachNodesToAll(replaySpecs); err != nil {
+		return []models.ReplaySpec{}, 0, err
+	}
+	return replaySpecs, total, nil
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayListLimit(t *testing.T) {
+	cases := []struct {
+		name      string
+		requested int
+		expected  int
+	}{
+		{"zero falls back to default", 0, defaultReplayListLimit},
+		{"negative falls back to default", -10, defaultReplayListLimit},
+		{"within bounds is kept as is", 25, 25},
+		{"above max is capped", maxReplayListLimit + 100, maxReplayListLimit},
+		{"exactly max is kept", maxReplayListLimit, maxReplayListLimit},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, replayListLimit(tc.requested))
+		})
+	}
+}
+
+func TestReplayOrderBy(t *testing.T) {
+	cases := []struct {
+		name      string
+		requested string
+		expected  string
+	}{
+		{"empty falls back to default", "", defaultReplayOrderBy},
+		{"allowlisted column with no direction defaults to asc", "status", "status asc"},
+		{"allowlisted column with explicit direction is kept", "created_at desc", "created_at desc"},
+		{"direction is case-insensitive", "updated_at DESC", "updated_at desc"},
+		{"column not on the allowlist falls back to default", "id", defaultReplayOrderBy},
+		{"sql injection attempt falls back to default", "created_at; drop table replay; --", defaultReplayOrderBy},
+		{"unknown direction falls back to default", "status sideways", defaultReplayOrderBy},
+		{"too many fields falls back to default", "status asc extra", defaultReplayOrderBy},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, replayOrderBy(tc.requested))
+		})
+	}
+}